@@ -0,0 +1,95 @@
+// Package backend defines a narrow key/value store interface and a
+// scheme-keyed registry of implementations, so the event gateway's storage
+// layer isn't hard-wired to libkv/etcd. Third parties can register their
+// own Backend (e.g. a Raft-replicated in-process store) without vendoring
+// libkv at all.
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned by Get when the requested key doesn't exist.
+// The libkv-backed List also returns it for a prefix with no keys; the
+// memory backend's List returns an empty slice instead. Implementations
+// should wrap or map their own not-found errors to this so callers (e.g.
+// the KV health watchdog) can recognize it regardless of which backend is
+// configured.
+var ErrKeyNotFound = errors.New("backend: key not found")
+
+// KVPair is a single key/value entry, along with the revision it was last
+// modified at. Revision is used for optimistic concurrency (AtomicPut,
+// AtomicDelete) and is opaque outside of a given Backend implementation.
+type KVPair struct {
+	Key      string
+	Value    []byte
+	Revision uint64
+}
+
+// WriteOptions controls how a Put is applied.
+type WriteOptions struct {
+	// TTL, if non-zero, expires the key after the given duration. Not
+	// every backend supports this; implementations that don't should
+	// document it rather than silently ignoring it.
+	TTL time.Duration
+}
+
+// Backend is the minimal key/value contract the rest of the event gateway
+// depends on. It's deliberately small: anything a libkv store, a Consul
+// client, or a single bbolt file can satisfy.
+type Backend interface {
+	Get(key string) (*KVPair, error)
+	Put(key string, value []byte, options *WriteOptions) error
+	Delete(key string) error
+	List(prefix string) ([]*KVPair, error)
+	AtomicPut(key string, value []byte, previous *KVPair, options *WriteOptions) (bool, *KVPair, error)
+	AtomicDelete(key string, previous *KVPair) (bool, error)
+	Watch(key string, stopCh <-chan struct{}) (<-chan *KVPair, error)
+	WatchTree(prefix string, stopCh <-chan struct{}) (<-chan []*KVPair, error)
+	Close() error
+}
+
+// Factory builds a Backend from a whole --backend URL, scheme included, e.g.
+// for "etcd://host1:2379,host2:2379" it receives that full string so it can
+// read the host list out of it.
+type Factory func(rawURL string) (Backend, error)
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Factory{}
+)
+
+// Register adds a Factory for a URL scheme, e.g. Register("etcd", New). It
+// panics on a duplicate scheme, matching libkv's own Register behavior,
+// since that can only indicate a programming error at init time.
+func Register(scheme string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[scheme]; exists {
+		panic(fmt.Sprintf("backend: scheme %q already registered", scheme))
+	}
+	registry[scheme] = f
+}
+
+// New builds a Backend from a URL such as "etcd://127.0.0.1:2379" or
+// "memory://./data.db", dispatching on its scheme.
+func New(rawURL string) (Backend, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing backend URL %q: %w", rawURL, err)
+	}
+
+	registryMu.Lock()
+	f, ok := registry[parsed.Scheme]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no backend registered for scheme %q", parsed.Scheme)
+	}
+
+	return f(rawURL)
+}
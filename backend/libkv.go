@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/serverless/libkv"
+	"github.com/serverless/libkv/store"
+	consul "github.com/serverless/libkv/store/consul"
+	etcd "github.com/serverless/libkv/store/etcd/v3"
+	zk "github.com/serverless/libkv/store/zookeeper"
+)
+
+func init() {
+	etcd.Register()
+	consul.Register()
+	zk.Register()
+
+	Register("etcd", libkvFactory(store.ETCDV3))
+	Register("consul", libkvFactory(store.CONSUL))
+	Register("zk", libkvFactory(store.ZK))
+}
+
+// libkvFactory returns a Factory that connects to one of libkv's own
+// backends: the event gateway's historical behavior, now reachable through
+// the generic Backend interface instead of store.Store directly.
+func libkvFactory(kvBackend store.Backend) Factory {
+	return func(rawURL string) (Backend, error) {
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+
+		hosts := strings.Split(parsed.Host, ",")
+		kv, err := libkv.NewStore(kvBackend, hosts, &store.Config{
+			ConnectionTimeout: 10 * time.Second,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &libkvBackend{kv: kv}, nil
+	}
+}
+
+// libkvBackend adapts a libkv store.Store to the narrower Backend
+// interface.
+type libkvBackend struct {
+	kv store.Store
+}
+
+func (b *libkvBackend) Get(key string) (*KVPair, error) {
+	pair, err := b.kv.Get(key)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return fromStoreKVPair(pair), nil
+}
+
+func (b *libkvBackend) Put(key string, value []byte, options *WriteOptions) error {
+	return b.kv.Put(key, value, toStoreWriteOptions(options))
+}
+
+func (b *libkvBackend) Delete(key string) error {
+	return b.kv.Delete(key)
+}
+
+func (b *libkvBackend) List(prefix string) ([]*KVPair, error) {
+	pairs, err := b.kv.List(prefix)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+
+	out := make([]*KVPair, len(pairs))
+	for i, p := range pairs {
+		out[i] = fromStoreKVPair(p)
+	}
+	return out, nil
+}
+
+func (b *libkvBackend) AtomicPut(key string, value []byte, previous *KVPair, options *WriteOptions) (bool, *KVPair, error) {
+	ok, pair, err := b.kv.AtomicPut(key, value, toStoreKVPair(previous), toStoreWriteOptions(options))
+	if err != nil {
+		return false, nil, err
+	}
+	return ok, fromStoreKVPair(pair), nil
+}
+
+func (b *libkvBackend) AtomicDelete(key string, previous *KVPair) (bool, error) {
+	return b.kv.AtomicDelete(key, toStoreKVPair(previous))
+}
+
+func (b *libkvBackend) Watch(key string, stopCh <-chan struct{}) (<-chan *KVPair, error) {
+	src, err := b.kv.Watch(key, stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *KVPair)
+	go func() {
+		defer close(out)
+		for pair := range src {
+			out <- fromStoreKVPair(pair)
+		}
+	}()
+	return out, nil
+}
+
+func (b *libkvBackend) WatchTree(prefix string, stopCh <-chan struct{}) (<-chan []*KVPair, error) {
+	src, err := b.kv.WatchTree(prefix, stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan []*KVPair)
+	go func() {
+		defer close(out)
+		for pairs := range src {
+			converted := make([]*KVPair, len(pairs))
+			for i, p := range pairs {
+				converted[i] = fromStoreKVPair(p)
+			}
+			out <- converted
+		}
+	}()
+	return out, nil
+}
+
+func (b *libkvBackend) Close() error {
+	b.kv.Close()
+	return nil
+}
+
+func fromStoreKVPair(p *store.KVPair) *KVPair {
+	if p == nil {
+		return nil
+	}
+	return &KVPair{Key: p.Key, Value: p.Value, Revision: p.LastIndex}
+}
+
+func toStoreKVPair(p *KVPair) *store.KVPair {
+	if p == nil {
+		return nil
+	}
+	return &store.KVPair{Key: p.Key, Value: p.Value, LastIndex: p.Revision}
+}
+
+func toStoreWriteOptions(o *WriteOptions) *store.WriteOptions {
+	if o == nil || o.TTL == 0 {
+		return nil
+	}
+	return &store.WriteOptions{TTL: o.TTL}
+}
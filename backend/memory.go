@@ -0,0 +1,291 @@
+package backend
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltBucket = "eventgateway"
+
+func init() {
+	Register("memory", newMemoryBackend)
+}
+
+// memoryBackend is an in-memory Backend for tests and single-node
+// deployments that don't want to run etcd/Consul/ZooKeeper at all. When the
+// URL carries a path (e.g. "memory://./data.db") it's also persisted to a
+// bbolt file so restarts don't lose state; "memory://" with no path keeps
+// everything in RAM only.
+type memoryBackend struct {
+	mu   sync.RWMutex
+	data map[string]*KVPair
+	rev  uint64
+
+	db *bolt.DB
+
+	watchersMu   sync.Mutex
+	watchers     map[string][]chan *KVPair
+	treeWatchers map[string][]chan []*KVPair
+}
+
+func newMemoryBackend(rawURL string) (Backend, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &memoryBackend{
+		data:         map[string]*KVPair{},
+		watchers:     map[string][]chan *KVPair{},
+		treeWatchers: map[string][]chan []*KVPair{},
+	}
+
+	// url.Parse splits a relative path like "./data.db" into Host="."
+	// and Path="/data.db", and an absolute one like "/data.db" into
+	// Host="" and Path="/data.db" — concatenating the two recovers the
+	// original path in both cases without guessing which one it was.
+	path := parsed.Host + parsed.Path
+	if path == "" {
+		return b, nil
+	}
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening bbolt file %q: %w", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltBucket))
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	b.db = db
+
+	if err := b.loadFromDisk(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *memoryBackend) loadFromDisk() error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(boltBucket))
+		return bucket.ForEach(func(k, v []byte) error {
+			b.rev++
+			value := append([]byte(nil), v...)
+			b.data[string(k)] = &KVPair{Key: string(k), Value: value, Revision: b.rev}
+			return nil
+		})
+	})
+}
+
+func (b *memoryBackend) persist(key string, value []byte, deleted bool) error {
+	if b.db == nil {
+		return nil
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(boltBucket))
+		if deleted {
+			return bucket.Delete([]byte(key))
+		}
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+func (b *memoryBackend) Get(key string) (*KVPair, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	pair, ok := b.data[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return copyKVPair(pair), nil
+}
+
+// Put does not support WriteOptions.TTL; keys written here never expire.
+func (b *memoryBackend) Put(key string, value []byte, _ *WriteOptions) error {
+	b.mu.Lock()
+	b.rev++
+	pair := &KVPair{Key: key, Value: value, Revision: b.rev}
+	b.data[key] = pair
+	err := b.persist(key, value, false)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	b.notify(key, pair)
+	return nil
+}
+
+func (b *memoryBackend) Delete(key string) error {
+	b.mu.Lock()
+	delete(b.data, key)
+	err := b.persist(key, nil, true)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	b.notify(key, nil)
+	return nil
+}
+
+func (b *memoryBackend) List(prefix string) ([]*KVPair, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var out []*KVPair
+	for k, v := range b.data {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, copyKVPair(v))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// AtomicPut, like Put, ignores WriteOptions.TTL; keys written here never
+// expire.
+func (b *memoryBackend) AtomicPut(key string, value []byte, previous *KVPair, options *WriteOptions) (bool, *KVPair, error) {
+	b.mu.Lock()
+	current, exists := b.data[key]
+
+	if previous == nil && exists {
+		b.mu.Unlock()
+		return false, copyKVPair(current), nil
+	}
+	if previous != nil && (!exists || current.Revision != previous.Revision) {
+		b.mu.Unlock()
+		var existingCopy *KVPair
+		if exists {
+			existingCopy = copyKVPair(current)
+		}
+		return false, existingCopy, nil
+	}
+
+	b.rev++
+	pair := &KVPair{Key: key, Value: value, Revision: b.rev}
+	b.data[key] = pair
+	err := b.persist(key, value, false)
+	b.mu.Unlock()
+	if err != nil {
+		return false, nil, err
+	}
+
+	b.notify(key, pair)
+	return true, copyKVPair(pair), nil
+}
+
+func (b *memoryBackend) AtomicDelete(key string, previous *KVPair) (bool, error) {
+	b.mu.Lock()
+	current, exists := b.data[key]
+	if !exists || (previous != nil && current.Revision != previous.Revision) {
+		b.mu.Unlock()
+		return false, nil
+	}
+
+	delete(b.data, key)
+	err := b.persist(key, nil, true)
+	b.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	b.notify(key, nil)
+	return true, nil
+}
+
+func (b *memoryBackend) Watch(key string, stopCh <-chan struct{}) (<-chan *KVPair, error) {
+	ch := make(chan *KVPair, 1)
+
+	b.watchersMu.Lock()
+	b.watchers[key] = append(b.watchers[key], ch)
+	b.watchersMu.Unlock()
+
+	go func() {
+		<-stopCh
+		b.watchersMu.Lock()
+		defer b.watchersMu.Unlock()
+		watchers := b.watchers[key]
+		for i, w := range watchers {
+			if w == ch {
+				b.watchers[key] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (b *memoryBackend) WatchTree(prefix string, stopCh <-chan struct{}) (<-chan []*KVPair, error) {
+	ch := make(chan []*KVPair, 1)
+
+	b.watchersMu.Lock()
+	b.treeWatchers[prefix] = append(b.treeWatchers[prefix], ch)
+	b.watchersMu.Unlock()
+
+	go func() {
+		<-stopCh
+		b.watchersMu.Lock()
+		defer b.watchersMu.Unlock()
+		watchers := b.treeWatchers[prefix]
+		for i, w := range watchers {
+			if w == ch {
+				b.treeWatchers[prefix] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (b *memoryBackend) notify(key string, pair *KVPair) {
+	b.watchersMu.Lock()
+	defer b.watchersMu.Unlock()
+
+	for _, ch := range b.watchers[key] {
+		select {
+		case ch <- pair:
+		default:
+		}
+	}
+
+	for prefix, chans := range b.treeWatchers {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		tree, _ := b.List(prefix)
+		for _, ch := range chans {
+			select {
+			case ch <- tree:
+			default:
+			}
+		}
+	}
+}
+
+func (b *memoryBackend) Close() error {
+	if b.db != nil {
+		return b.db.Close()
+	}
+	return nil
+}
+
+func copyKVPair(p *KVPair) *KVPair {
+	value := append([]byte(nil), p.Value...)
+	return &KVPair{Key: p.Key, Value: value, Revision: p.Revision}
+}
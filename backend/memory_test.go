@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestMemoryBackend(t *testing.T, rawURL string) *memoryBackend {
+	t.Helper()
+	b, err := newMemoryBackend(rawURL)
+	if err != nil {
+		t.Fatalf("newMemoryBackend(%q): %v", rawURL, err)
+	}
+	return b.(*memoryBackend)
+}
+
+func TestMemoryBackendAtomicPutCAS(t *testing.T) {
+	b := newTestMemoryBackend(t, "memory://")
+	defer b.Close()
+
+	ok, created, err := b.AtomicPut("key", []byte("v1"), nil, nil)
+	if err != nil || !ok {
+		t.Fatalf("initial AtomicPut: ok=%v err=%v", ok, err)
+	}
+
+	// A second AtomicPut with previous=nil must fail now that the key exists.
+	ok, existing, err := b.AtomicPut("key", []byte("v2"), nil, nil)
+	if err != nil || ok {
+		t.Fatalf("AtomicPut with nil previous against an existing key should fail, got ok=%v err=%v", ok, err)
+	}
+	if existing == nil || existing.Revision != created.Revision {
+		t.Fatalf("expected the current pair back on CAS failure, got %+v", existing)
+	}
+
+	// Racing the same stale previous from many goroutines: exactly one wins.
+	const attempts = 20
+	var wg sync.WaitGroup
+	var wins int32
+	var mu sync.Mutex
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _, err := b.AtomicPut("key", []byte("v3"), created, nil)
+			if err != nil {
+				t.Errorf("AtomicPut: %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Fatalf("expected exactly one AtomicPut to win the CAS race, got %d", wins)
+	}
+
+	// AtomicDelete against the now-stale revision must fail.
+	ok, err = b.AtomicDelete("key", created)
+	if err != nil || ok {
+		t.Fatalf("AtomicDelete against a stale revision should fail, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestMemoryBackendWatchSeesConcurrentPuts(t *testing.T) {
+	b := newTestMemoryBackend(t, "memory://")
+	defer b.Close()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	ch, err := b.Watch("key", stopCh)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	go func() {
+		if err := b.Put("key", []byte("v1"), nil); err != nil {
+			t.Errorf("Put: %v", err)
+		}
+	}()
+
+	select {
+	case pair := <-ch:
+		if pair == nil || string(pair.Value) != "v1" {
+			t.Fatalf("expected to observe v1, got %+v", pair)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for watch notification")
+	}
+}
+
+func TestMemoryBackendReloadsFromDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	rawURL := "memory://" + path
+
+	b := newTestMemoryBackend(t, rawURL)
+	if err := b.Put("key", []byte("persisted"), nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := newTestMemoryBackend(t, rawURL)
+	defer reopened.Close()
+
+	pair, err := reopened.Get("key")
+	if err != nil {
+		t.Fatalf("Get after reload: %v", err)
+	}
+	if string(pair.Value) != "persisted" {
+		t.Fatalf("expected reloaded value %q, got %q", "persisted", pair.Value)
+	}
+}
@@ -0,0 +1,228 @@
+// Package app holds the event gateway's startup sequence so it can be
+// embedded by tools other than the event-gateway binary itself, such as
+// eventgateway-dump-metrics, which needs an in-process gateway to scrape
+// rather than one it has to exec and tear down as a subprocess.
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/serverless/event-gateway/api"
+	"github.com/serverless/event-gateway/backend"
+	"github.com/serverless/event-gateway/internal/cache"
+	"github.com/serverless/event-gateway/internal/config"
+	"github.com/serverless/event-gateway/internal/embedded"
+	"github.com/serverless/event-gateway/internal/httpapi"
+	"github.com/serverless/event-gateway/internal/kvhealth"
+	"github.com/serverless/event-gateway/internal/metrics"
+	"github.com/serverless/event-gateway/internal/sync"
+	"github.com/serverless/event-gateway/plugin"
+	"github.com/serverless/event-gateway/router"
+)
+
+// Config is the fully-resolved configuration needed to run a gateway
+// instance: whatever mix of --config file and CLI flags produced it has
+// already been merged by the caller.
+type Config struct {
+	Version string
+
+	LogLevel  zapcore.Level
+	LogFormat string
+
+	// Backend is the primary backend.New-able URL, e.g.
+	// "etcd://host1:2379,host2:2379" or "memory://./data.db".
+	Backend string
+	// BackendHosts are per-host backend.New-able URLs the KV health
+	// watchdog cycles through on reconnect, e.g. one "etcd://hostN:2379"
+	// per --db-hosts entry. The watchdog uses this list whatever the
+	// backend; when --backend is set, main passes just that one URL, so a
+	// reconnect reopens the same backend.
+	BackendHosts     []string
+	UnhealthyTimeout time.Duration
+
+	DevelopmentMode bool
+	Embed           bool
+	EmbedPeerAddr   string
+	EmbedCliAddr    string
+	EmbedDataDir    string
+	EmbeddedCluster *config.EmbeddedConfig
+
+	Plugins []string
+
+	EventsPort uint
+	EventsTLS  config.TLSConfig
+	ConfigPort uint
+	ConfigTLS  config.TLSConfig
+}
+
+// App is a running gateway instance.
+type App struct {
+	Log           *zap.Logger
+	LogLevel      zap.AtomicLevel
+	PluginManager *plugin.Manager
+	EventsCerts   *httpapi.CertStore
+	ConfigCerts   *httpapi.CertStore
+
+	shutdownGuard *sync.ShutdownGuard
+	router        *router.Router
+	cancelMonitor context.CancelFunc
+}
+
+// Run starts a gateway instance: embedded etcd (if configured), the KV
+// health watchdog, the plugin manager, the router, and both HTTP APIs. It
+// returns once the APIs are listening; callers should defer a.Wait() to
+// block until an external trigger (e.g. a signal handler) asks the
+// ShutdownGuard to shut down, or call a.Shutdown() themselves for tests and
+// tools that need to tear it down deterministically instead of waiting on
+// one.
+func Run(cfg Config) (*App, error) {
+	zapCfg := newLogger(cfg)
+	log, err := zapCfg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("building logger: %w", err)
+	}
+
+	shutdownGuard := sync.NewShutdownGuard()
+
+	if cfg.DevelopmentMode {
+		embedded.EmbedEtcd(cfg.EmbedDataDir, cfg.EmbedPeerAddr, cfg.EmbedCliAddr, shutdownGuard)
+	} else if cfg.Embed {
+		if cfg.EmbeddedCluster == nil {
+			return nil, fmt.Errorf("--embed requires an embedded cluster config")
+		}
+		if _, err := embedded.EmbedCluster(*cfg.EmbeddedCluster, shutdownGuard, log); err != nil {
+			return nil, fmt.Errorf("starting embedded etcd cluster member: %w", err)
+		}
+	}
+
+	rawKV, err := backend.New(cfg.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("creating KV client: %w", err)
+	}
+
+	kvMonitor := kvhealth.NewMonitor(rawKV, cfg.BackendHosts, cfg.UnhealthyTimeout, log)
+	monitorCtx, cancelMonitor := context.WithCancel(context.Background())
+	go kvMonitor.Start(monitorCtx)
+	var kv backend.Backend = kvMonitor
+
+	pluginManager := plugin.NewManager(cfg.Plugins, log)
+	if err := pluginManager.Connect(); err != nil {
+		cancelMonitor()
+		return nil, fmt.Errorf("loading plugins: %w", err)
+	}
+
+	targetCache := cache.NewTarget("/serverless-event-gateway", kv, log)
+	r := router.New(targetCache, pluginManager, metrics.DroppedPubSubEvents, log)
+	r.StartWorkers()
+
+	eventsCerts, err := certStoreFor(cfg.EventsTLS)
+	if err != nil {
+		cancelMonitor()
+		return nil, fmt.Errorf("setting up events API TLS: %w", err)
+	}
+	configCerts, err := certStoreFor(cfg.ConfigTLS)
+	if err != nil {
+		cancelMonitor()
+		return nil, fmt.Errorf("setting up config API TLS: %w", err)
+	}
+
+	api.StartEventsAPI(httpapi.Config{
+		KV:            kv,
+		Log:           log,
+		Port:          cfg.EventsPort,
+		ShutdownGuard: shutdownGuard,
+		Certs:         eventsCerts,
+		Healthy:       kvMonitor.Healthy,
+	}, r)
+
+	api.StartConfigAPI(httpapi.Config{
+		KV:            kv,
+		Log:           log,
+		Port:          cfg.ConfigPort,
+		ShutdownGuard: shutdownGuard,
+		Certs:         configCerts,
+	})
+
+	return &App{
+		Log:           log,
+		LogLevel:      zapCfg.Level,
+		PluginManager: pluginManager,
+		EventsCerts:   eventsCerts,
+		ConfigCerts:   configCerts,
+		shutdownGuard: shutdownGuard,
+		router:        r,
+		cancelMonitor: cancelMonitor,
+	}, nil
+}
+
+// Wait blocks until the app is asked to shut down (e.g. via signal, wired
+// up by the caller into ShutdownGuard), then drains the router and kills
+// plugins before returning.
+func (a *App) Wait() {
+	a.shutdownGuard.Wait()
+	a.router.Drain()
+	a.cancelMonitor()
+
+	if a.PluginManager != nil {
+		a.PluginManager.Kill()
+	}
+}
+
+// Shutdown requests the app's own ShutdownGuard to shut down and blocks
+// until it has drained, the way Wait() does for an externally-triggered
+// shutdown. Tests and tools that embed an App themselves (rather than
+// running it until a signal handler trips the ShutdownGuard) should call
+// this instead of Wait() so the embedded etcd member, plugins, and router
+// are actually torn down before the caller moves on.
+func (a *App) Shutdown() {
+	a.shutdownGuard.Shutdown()
+	a.Wait()
+}
+
+func certStoreFor(tlsCfg config.TLSConfig) (*httpapi.CertStore, error) {
+	if !tlsCfg.Enabled() {
+		return nil, nil
+	}
+	return httpapi.NewCertStore(tlsCfg)
+}
+
+func newLogger(cfg Config) zap.Config {
+	zapCfg := zap.Config{
+		Level:            zap.NewAtomicLevelAt(cfg.LogLevel),
+		Development:      false,
+		Sampling:         &zap.SamplingConfig{Initial: 100, Thereafter: 100},
+		Encoding:         "json",
+		OutputPaths:      []string{"stderr"},
+		ErrorOutputPaths: []string{"stderr"},
+	}
+
+	if cfg.DevelopmentMode {
+		zapCfg.Sampling = nil
+		zapCfg.Encoding = "console"
+	}
+
+	switch cfg.LogFormat {
+	case "text":
+		zapCfg.Encoding = "console"
+	case "json":
+		zapCfg.Encoding = "json"
+	}
+
+	if zapCfg.Encoding == "json" {
+		zapCfg.EncoderConfig = zap.NewProductionEncoderConfig()
+	}
+	if zapCfg.Encoding == "console" {
+		zapCfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+
+	zapCfg.DisableCaller = true
+	zapCfg.DisableStacktrace = true
+
+	return zapCfg
+}
+
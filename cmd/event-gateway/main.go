@@ -7,48 +7,44 @@ import (
 	"strings"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/serverless/libkv"
-	"github.com/serverless/libkv/store"
-	etcd "github.com/serverless/libkv/store/etcd/v3"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
-	"github.com/serverless/event-gateway/api"
-	"github.com/serverless/event-gateway/internal/cache"
-	"github.com/serverless/event-gateway/internal/embedded"
-	"github.com/serverless/event-gateway/internal/httpapi"
+	"github.com/serverless/event-gateway/cmd/event-gateway/app"
+	"github.com/serverless/event-gateway/internal/config"
 	"github.com/serverless/event-gateway/internal/metrics"
-	"github.com/serverless/event-gateway/internal/sync"
-	"github.com/serverless/event-gateway/plugin"
-	"github.com/serverless/event-gateway/router"
 )
 
 var version = "dev"
 
 func init() {
-	etcd.Register()
+	// Backend schemes (etcd, consul, zk, memory) register themselves via
+	// the backend package's own init(), pulled in transitively through
+	// cmd/event-gateway/app.
 
-	prometheus.MustRegister(metrics.RequestDuration)
-	prometheus.MustRegister(metrics.DroppedPubSubEvents)
+	metrics.MustRegisterAll()
 }
 
 // nolint: gocyclo
 func main() {
 	showVersion := flag.Bool("version", false, "Show version.")
+	configPath := flag.String("config", "", "Path to a YAML configuration file. CLI flags below override values loaded from it.")
 	logLevel := zap.LevelFlag("log-level", zap.InfoLevel, `The level of logging to show after the event gateway has started. The available log levels are "debug", "info", "warn", and "err".`)
 	logFormat := flag.String("log-format", "", `The format of logs. The available formats are "text", "json".)`)
-	dbHosts := flag.String("db-hosts", "127.0.0.1:2379", "Comma-separated list of database hosts to connect to.")
+	dbHosts := flag.String("db-hosts", "127.0.0.1:2379", "Comma-separated list of database hosts to connect to. Ignored if --backend is set.")
+	backendURL := flag.String("backend", "", "Backend connection URL, e.g. etcd://host1:2379,host2:2379, consul://host:8500, zk://host:2181, or memory://./data.db. Defaults to etcd against --db-hosts for backward compatibility.")
 	developmentMode := flag.Bool("dev", false, `Run in development mode with embedded etcd and "text" log format.`)
 	embedPeerAddr := flag.String("embed-peer-addr", "http://127.0.0.1:2380", "Address for testing embedded etcd to receive peer connections.")
 	embedCliAddr := flag.String("embed-cli-addr", "http://127.0.0.1:2379", "Address for testing embedded etcd to receive client connections.")
 	embedDataDir := flag.String("embed-data-dir", "default.etcd", "Path for testing embedded etcd to store its state.")
+	embedMode := flag.Bool("embed", false, `Run a production, potentially multi-node, embedded etcd member as described by the "embedded:" section of --config. Unlike --dev this does not imply a single-node dev cluster or "text" logging.`)
 	configPort := flag.Uint("config-port", 4001, "Port to serve configuration API on.")
 	configTLSCrt := flag.String("config-tls-cert", "", "Path to configuration API TLS certificate file.")
 	configTLSKey := flag.String("config-tls-key", "", "Path to configuration API TLS key file.")
 	eventsPort := flag.Uint("events-port", 4000, "Port to serve events API on.")
 	eventsTLSCrt := flag.String("events-tls-cert", "", "Path to events API TLS certificate file.")
 	eventsTLSKey := flag.String("events-tls-key", "", "Path to events API TLS key file.")
+	unhealthyTimeout := flag.Duration("unhealthy-timeout", 60*time.Second, "How long the KV store can fail health checks before the client reconnects to the next --db-hosts entry.")
 	plugins := paths{}
 	flag.Var(&plugins, "plugin", "Path to a plugin to load.")
 	flag.Parse()
@@ -58,120 +54,188 @@ func main() {
 		os.Exit(0)
 	}
 
-	log, err := logger(*developmentMode, *logLevel, *logFormat).Build()
-	if err != nil {
-		panic(err)
+	fileCfg := config.Default()
+	if *configPath != "" {
+		loaded, err := config.Load(*configPath)
+		if err != nil {
+			panic(err)
+		}
+		fileCfg = loaded
 	}
-	defer log.Sync()
-
-	shutdownGuard := sync.NewShutdownGuard()
-
-	if *developmentMode {
-		embedded.EmbedEtcd(*embedDataDir, *embedPeerAddr, *embedCliAddr, shutdownGuard)
+	applyFlagOverrides(fileCfg, &plugins, dbHosts, backendURL, logLevel, logFormat, eventsPort, configPort, eventsTLSCrt, eventsTLSKey, configTLSCrt, configTLSKey)
+
+	dbHostList := strings.Split(*dbHosts, ",")
+	primaryBackend, backendHosts := resolveBackendURLs(*backendURL, dbHostList)
+
+	appCfg := app.Config{
+		Version:          version,
+		LogLevel:         *logLevel,
+		LogFormat:        fileCfg.LogFormat,
+		Backend:          primaryBackend,
+		BackendHosts:     backendHosts,
+		UnhealthyTimeout: *unhealthyTimeout,
+		DevelopmentMode:  *developmentMode,
+		Embed:            *embedMode,
+		EmbedPeerAddr:    *embedPeerAddr,
+		EmbedCliAddr:     *embedCliAddr,
+		EmbedDataDir:     *embedDataDir,
+		EmbeddedCluster:  fileCfg.Embedded,
+		Plugins:          plugins,
+		EventsPort:       *eventsPort,
+		EventsTLS:        fileCfg.EventsAPI.TLS,
+		ConfigPort:       *configPort,
+		ConfigTLS:        fileCfg.ConfigAPI.TLS,
 	}
 
-	kv, err := libkv.NewStore(
-		store.ETCDV3,
-		strings.Split(*dbHosts, ","),
-		&store.Config{
-			ConnectionTimeout: 10 * time.Second,
-		},
-	)
+	a, err := app.Run(appCfg)
 	if err != nil {
-		log.Fatal("Cannot create KV client.", zap.Error(err))
+		panic(err)
+	}
+	defer a.Log.Sync()
+
+	if *configPath != "" {
+		watcher, err := config.NewWatcher(*configPath, a.Log, func(next *config.Config) {
+			onConfigReload(a, next)
+		})
+		if err != nil {
+			a.Log.Warn("Cannot watch config file for changes.", zap.Error(err))
+		} else {
+			defer watcher.Close()
+		}
 	}
-
-	pluginManager := plugin.NewManager(plugins, log)
-	err = pluginManager.Connect()
-	if err != nil {
-		log.Fatal("Loading plugins failed.", zap.Error(err))
-	}
-
-	targetCache := cache.NewTarget("/serverless-event-gateway", kv, log)
-	router := router.New(targetCache, pluginManager, metrics.DroppedPubSubEvents, log)
-	router.StartWorkers()
-
-	api.StartEventsAPI(httpapi.Config{
-		KV:            kv,
-		Log:           log,
-		TLSCrt:        eventsTLSCrt,
-		TLSKey:        eventsTLSKey,
-		Port:          *eventsPort,
-		ShutdownGuard: shutdownGuard,
-	}, router)
-
-	api.StartConfigAPI(httpapi.Config{
-		KV:            kv,
-		Log:           log,
-		TLSCrt:        configTLSCrt,
-		TLSKey:        configTLSKey,
-		Port:          *configPort,
-		ShutdownGuard: shutdownGuard,
-	})
 
 	if *developmentMode {
 		eventProto := "http"
-		if *eventsTLSCrt != "" && *eventsTLSKey != "" {
+		if appCfg.EventsTLS.Enabled() {
 			eventProto = "https"
 		}
 		configProto := "http"
-		if *configTLSCrt != "" && *configTLSKey != "" {
+		if appCfg.ConfigTLS.Enabled() {
 			configProto = "https"
 		}
 
-		log.Info(fmt.Sprintf("Running in development mode with embedded etcd. Event API listening on %s://localhost:%d. Config API listening on %s://localhost:%d.", eventProto, *eventsPort, configProto, *configPort))
+		a.Log.Info(fmt.Sprintf("Running in development mode with embedded etcd. Event API listening on %s://localhost:%d. Config API listening on %s://localhost:%d.", eventProto, *eventsPort, configProto, *configPort))
 	}
 
-	shutdownGuard.Wait()
-	router.Drain()
+	a.Wait()
+}
+
+// resolveBackendURLs derives the primary backend.New-able URL and the
+// per-host URLs the KV health watchdog fails over between. When --backend
+// is set explicitly it's used as-is and is its own (sole) failover target,
+// since the gateway can't assume a scheme-specific host list syntax for an
+// arbitrary backend. Otherwise it falls back to etcd against --db-hosts,
+// matching the gateway's historical behavior.
+func resolveBackendURLs(backendURL string, dbHosts []string) (primary string, hosts []string) {
+	if backendURL != "" {
+		return backendURL, []string{backendURL}
+	}
 
-	if pluginManager != nil {
-		pluginManager.Kill()
+	hosts = make([]string, len(dbHosts))
+	for i, host := range dbHosts {
+		hosts[i] = "etcd://" + host
 	}
+	return "etcd://" + strings.Join(dbHosts, ","), hosts
 }
 
-const (
-	consoleEncoding = "console"
-	jsonEncoding    = "json"
-)
+// applyFlagOverrides copies explicitly-set CLI flags on top of a config
+// loaded from --config, preserving backward compatibility with flag-only
+// deployments.
+func applyFlagOverrides(cfg *config.Config, plugins *paths, dbHosts, backendURL *string, logLevel *zapcore.Level, logFormat *string, eventsPort, configPort *uint, eventsTLSCrt, eventsTLSKey, configTLSCrt, configTLSKey *string) {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
 
-func logger(dev bool, level zapcore.Level, format string) zap.Config {
-	cfg := zap.Config{
-		Level:            zap.NewAtomicLevelAt(level),
-		Development:      false,
-		Sampling:         &zap.SamplingConfig{Initial: 100, Thereafter: 100},
-		Encoding:         "json",
-		OutputPaths:      []string{"stderr"},
-		ErrorOutputPaths: []string{"stderr"},
+	if set["db-hosts"] {
+		cfg.DBHosts = strings.Split(*dbHosts, ",")
 	}
-
-	if dev {
-		cfg.Sampling = nil
-		cfg.Encoding = consoleEncoding
+	if set["backend"] {
+		cfg.Backend = *backendURL
+	}
+	if set["log-level"] {
+		cfg.LogLevel = logLevel.String()
+	}
+	if set["log-format"] {
+		cfg.LogFormat = *logFormat
+	}
+	if set["plugin"] {
+		cfg.Plugins = *plugins
+	}
+	if set["events-port"] {
+		cfg.EventsAPI.Port = *eventsPort
+	}
+	if set["config-port"] {
+		cfg.ConfigAPI.Port = *configPort
+	}
+	if set["events-tls-cert"] {
+		cfg.EventsAPI.TLS.Cert = *eventsTLSCrt
+	}
+	if set["events-tls-key"] {
+		cfg.EventsAPI.TLS.Key = *eventsTLSKey
+	}
+	if set["config-tls-cert"] {
+		cfg.ConfigAPI.TLS.Cert = *configTLSCrt
+	}
+	if set["config-tls-key"] {
+		cfg.ConfigAPI.TLS.Key = *configTLSKey
 	}
 
-	if format != "" {
-		if format == "text" {
-			cfg.Encoding = consoleEncoding
-		} else if format == jsonEncoding {
-			cfg.Encoding = jsonEncoding
-		} else {
-			cfg.Encoding = ""
+	if len(cfg.Plugins) > 0 {
+		*plugins = cfg.Plugins
+	}
+	if cfg.LogLevel != "" {
+		if parsed, err := zapcore.ParseLevel(cfg.LogLevel); err == nil {
+			*logLevel = parsed
 		}
 	}
-
-	if cfg.Encoding == jsonEncoding {
-		cfg.EncoderConfig = zap.NewProductionEncoderConfig()
+	if len(cfg.DBHosts) > 0 {
+		*dbHosts = strings.Join(cfg.DBHosts, ",")
+	}
+	if cfg.Backend != "" {
+		*backendURL = cfg.Backend
+	}
+	if cfg.EventsAPI.Port != 0 {
+		*eventsPort = cfg.EventsAPI.Port
+	}
+	if cfg.ConfigAPI.Port != 0 {
+		*configPort = cfg.ConfigAPI.Port
+	}
+	if cfg.EventsAPI.TLS.Cert != "" {
+		*eventsTLSCrt = cfg.EventsAPI.TLS.Cert
 	}
+	if cfg.EventsAPI.TLS.Key != "" {
+		*eventsTLSKey = cfg.EventsAPI.TLS.Key
+	}
+	if cfg.ConfigAPI.TLS.Cert != "" {
+		*configTLSCrt = cfg.ConfigAPI.TLS.Cert
+	}
+	if cfg.ConfigAPI.TLS.Key != "" {
+		*configTLSKey = cfg.ConfigAPI.TLS.Key
+	}
+}
 
-	if cfg.Encoding == consoleEncoding {
-		cfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+// onConfigReload applies the subset of config that can change without a
+// restart: log level, plugin list, and TLS certs for both listeners.
+func onConfigReload(a *app.App, next *config.Config) {
+	if a.EventsCerts != nil {
+		if err := a.EventsCerts.Reload(next.EventsAPI.TLS); err != nil {
+			a.Log.Warn("Failed to reload events API TLS cert.", zap.Error(err))
+		}
+	}
+	if a.ConfigCerts != nil {
+		if err := a.ConfigCerts.Reload(next.ConfigAPI.TLS); err != nil {
+			a.Log.Warn("Failed to reload config API TLS cert.", zap.Error(err))
+		}
 	}
 
-	cfg.DisableCaller = true
-	cfg.DisableStacktrace = true
+	if parsed, err := zapcore.ParseLevel(next.LogLevel); err == nil {
+		a.LogLevel.SetLevel(parsed)
+	}
 
-	return cfg
+	if a.PluginManager != nil {
+		if err := a.PluginManager.Reload(next.Plugins); err != nil {
+			a.Log.Warn("Failed to reload plugin list.", zap.Error(err))
+		}
+	}
 }
 
 type paths []string
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// releaseBinaryURLFormat mirrors how etcd publishes etcd-dump-metrics: one
+// plain executable per OS/arch next to the release's other assets, which
+// dumpPreviousRelease downloads and runs as-is.
+const releaseBinaryURLFormat = "https://github.com/serverless/event-gateway/releases/download/%s/eventgateway-dump-metrics-%s-%s-%s"
+
+// dumpPreviousRelease fetches the eventgateway-dump-metrics binary published
+// with the given release tag and runs it to get its metric documentation,
+// so the current build's metric surface can be diffed against it.
+func dumpPreviousRelease(version string) (string, error) {
+	url := fmt.Sprintf(releaseBinaryURLFormat, version, version, runtime.GOOS, runtime.GOARCH)
+
+	dir, err := ioutil.TempDir("", "eventgateway-dump-metrics-prev-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	binPath := filepath.Join(dir, "eventgateway-dump-metrics")
+	if err := download(url, binPath); err != nil {
+		return "", fmt.Errorf("downloading %s: %w", url, err)
+	}
+
+	out, err := exec.Command(binPath, "--output", "-").Output()
+	if err != nil {
+		return "", fmt.Errorf("running downloaded binary: %w", err)
+	}
+
+	return string(out), nil
+}
+
+func download(url, dest string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, resp.Body)
+	return err
+}
+
+var metricNameRE = regexp.MustCompile("`([a-zA-Z0-9_]+)`")
+
+// diffMetricDocs compares two rendered markdown tables and reports metric
+// names that were added or removed, one per line, in no particular order.
+// It returns "" when the names match. main prints a non-empty report to
+// stderr; the report does not change the tool's exit status.
+func diffMetricDocs(prev, current string) string {
+	prevNames := metricNames(prev)
+	currentNames := metricNames(current)
+
+	var added, removed []string
+	for name := range currentNames {
+		if !prevNames[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range prevNames {
+		if !currentNames[name] {
+			removed = append(removed, name)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, name := range added {
+		fmt.Fprintf(&b, "  + %s\n", name)
+	}
+	for _, name := range removed {
+		fmt.Fprintf(&b, "  - %s\n", name)
+	}
+	return b.String()
+}
+
+func metricNames(doc string) map[string]bool {
+	names := map[string]bool{}
+	for _, line := range strings.Split(doc, "\n") {
+		m := metricNameRE.FindStringSubmatch(line)
+		if m != nil {
+			names[m[1]] = true
+		}
+	}
+	return names
+}
@@ -0,0 +1,62 @@
+// Command eventgateway-dump-metrics boots a gateway in-process with
+// embedded etcd on ephemeral ports, scrapes its own /metrics endpoint, and
+// writes a markdown table documenting every exposed metric. It's patterned
+// after etcd's etcd-dump-metrics: run it as part of `make docs` and fail CI
+// if the checked-in doc drifts, or pass --download-ver to see which
+// metrics changed since a prior release.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/serverless/event-gateway/internal/metrics"
+)
+
+func init() {
+	// dumpCurrentMetrics runs a real gateway in-process via app.Run, which
+	// updates these collectors same as the production binary - but
+	// Prometheus registration isn't transitive through an import, so this
+	// tool needs its own call or /metrics would only ever show the default
+	// Go/process collectors, never eventgateway_*.
+	metrics.MustRegisterAll()
+}
+
+func main() {
+	output := flag.String("output", "-", `Where to write the markdown table. "-" means stdout.`)
+	downloadVer := flag.String("download-ver", "", "A prior released version (e.g. v0.10.0) whose eventgateway-dump-metrics binary to fetch and diff the current metric surface against.")
+	timeout := flag.String("timeout", "30s", "How long to wait for the in-process gateway's /metrics endpoint to become reachable.")
+	flag.Parse()
+
+	families, err := dumpCurrentMetrics(*timeout)
+	if err != nil {
+		log.Fatalf("dumping metrics: %v", err)
+	}
+
+	doc := renderMarkdown(families)
+
+	if *downloadVer != "" {
+		prevDoc, err := dumpPreviousRelease(*downloadVer)
+		if err != nil {
+			log.Fatalf("fetching metrics for %s: %v", *downloadVer, err)
+		}
+
+		report := diffMetricDocs(prevDoc, doc)
+		if report != "" {
+			fmt.Fprintln(os.Stderr, "Metric surface changed since "+*downloadVer+":")
+			fmt.Fprintln(os.Stderr, report)
+		}
+	}
+
+	if *output == "-" {
+		fmt.Print(doc)
+		return
+	}
+
+	if err := ioutil.WriteFile(*output, []byte(doc), 0644); err != nil {
+		log.Fatalf("writing %s: %v", *output, err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// renderMarkdown writes a sorted markdown table of every metric family:
+// name, type, help text, and the label set collected across its samples.
+func renderMarkdown(families []*dto.MetricFamily) string {
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+
+	var b strings.Builder
+	b.WriteString("| Name | Type | Labels | Help |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+
+	for _, f := range families {
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n",
+			f.GetName(), strings.ToLower(f.GetType().String()), labelSet(f), f.GetHelp())
+	}
+
+	return b.String()
+}
+
+// labelSet collects the union of label names seen across a family's
+// samples, e.g. `method, path, status` for RequestDuration.
+func labelSet(f *dto.MetricFamily) string {
+	seen := map[string]bool{}
+	var names []string
+
+	for _, m := range f.GetMetric() {
+		for _, lp := range m.GetLabel() {
+			if !seen[lp.GetName()] {
+				seen[lp.GetName()] = true
+				names = append(names, lp.GetName())
+			}
+		}
+	}
+
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/serverless/event-gateway/cmd/event-gateway/app"
+)
+
+// dumpCurrentMetrics boots a gateway in-process on ephemeral ports with
+// embedded etcd, waits for its config API (which serves /metrics) to come
+// up, and returns the scraped metric families.
+func dumpCurrentMetrics(timeout string) ([]*dto.MetricFamily, error) {
+	deadline, err := time.ParseDuration(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --timeout: %w", err)
+	}
+
+	dataDir, err := ioutil.TempDir("", "eventgateway-dump-metrics-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dataDir)
+
+	peerAddr, err := freeLoopbackURL()
+	if err != nil {
+		return nil, fmt.Errorf("allocating peer port: %w", err)
+	}
+	clientAddr, err := freeLoopbackURL()
+	if err != nil {
+		return nil, fmt.Errorf("allocating client port: %w", err)
+	}
+	eventsPort, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("allocating events port: %w", err)
+	}
+	configPort, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("allocating config port: %w", err)
+	}
+
+	a, err := app.Run(app.Config{
+		Version:         "dump-metrics",
+		LogLevel:        zapcore.ErrorLevel,
+		Backend:         "etcd://" + clientAddr,
+		DevelopmentMode: true,
+		EmbedPeerAddr:   "http://" + peerAddr,
+		EmbedCliAddr:    "http://" + clientAddr,
+		EmbedDataDir:    dataDir,
+		EventsPort:      eventsPort,
+		ConfigPort:      configPort,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("starting in-process gateway: %w", err)
+	}
+	defer a.Shutdown()
+
+	metricsURL := fmt.Sprintf("http://127.0.0.1:%d/metrics", configPort)
+	if err := waitReachable(metricsURL, deadline); err != nil {
+		return nil, err
+	}
+
+	return scrape(metricsURL)
+}
+
+// waitReachable polls url until it returns 200 or deadline elapses.
+func waitReachable(url string, deadline time.Duration) error {
+	cutoff := time.Now().Add(deadline)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		if time.Now().After(cutoff) {
+			return fmt.Errorf("%s did not become reachable within %s", url, deadline)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// scrape fetches and parses a Prometheus text-format endpoint.
+func scrape(url string) ([]*dto.MetricFamily, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parser expfmt.TextParser
+	parsed, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics: %w", err)
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(parsed))
+	for _, f := range parsed {
+		families = append(families, f)
+	}
+	return families, nil
+}
+
+func freePort() (uint, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+func freeLoopbackURL() (string, error) {
+	port, err := freePort()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("127.0.0.1:%d", port), nil
+}
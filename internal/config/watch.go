@@ -0,0 +1,92 @@
+package config
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// Watcher reloads a Config from disk whenever the underlying file changes
+// and hands the new value to OnChange. It only ever replaces the process's
+// view of mutable settings (log level, plugin list, TLS certs) - listener
+// ports and the embedded etcd topology still require a restart.
+type Watcher struct {
+	path     string
+	log      *zap.Logger
+	watcher  *fsnotify.Watcher
+	OnChange func(*Config)
+}
+
+// NewWatcher starts watching path for changes and calls onChange with the
+// freshly parsed Config every time the file is written or recreated (editors
+// frequently replace a file rather than writing it in place).
+func NewWatcher(path string, log *zap.Logger, onChange func(*Config)) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fsw.Add(path); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	w := &Watcher{
+		path:     path,
+		log:      log,
+		watcher:  fsw,
+		OnChange: onChange,
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+
+			if event.Op&(fsnotify.Rename|fsnotify.Remove) != 0 {
+				// fsnotify watches the inode, not the path: a Rename or
+				// Remove means whatever's now at w.path (e.g. the file an
+				// editor or a ConfigMap update just renamed into place) is
+				// a different inode the watch doesn't cover yet. Re-add it
+				// or every subsequent change goes unnoticed until restart.
+				if err := w.watcher.Add(w.path); err != nil {
+					w.log.Warn("Failed to re-watch config file after it was replaced.", zap.Error(err))
+				}
+			}
+
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			cfg, err := Load(w.path)
+			if err != nil {
+				w.log.Warn("Failed to reload config file, keeping previous config.", zap.Error(err))
+				continue
+			}
+
+			w.log.Info("Config file changed, reloading.", zap.String("path", w.path))
+			w.OnChange(cfg)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.log.Warn("Config watcher error.", zap.Error(err))
+		}
+	}
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
@@ -0,0 +1,265 @@
+// Package embedded runs an etcd server in-process so the event gateway can
+// act as a self-contained control plane without a separately operated etcd
+// deployment.
+package embedded
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/embed"
+	"go.uber.org/zap"
+
+	"github.com/serverless/event-gateway/internal/config"
+	"github.com/serverless/event-gateway/internal/httpapi"
+	"github.com/serverless/event-gateway/internal/sync"
+)
+
+const startupTimeout = 60 * time.Second
+
+// EmbedEtcd starts a single-node embedded etcd suitable for --dev. It is a
+// thin wrapper around EmbedCluster with a one-member "new" cluster, kept
+// around so existing --dev deployments don't need a config file.
+func EmbedEtcd(dataDir, peerAddr, clientAddr string, shutdownGuard *sync.ShutdownGuard) {
+	cfg := config.EmbeddedConfig{
+		Name:                "default",
+		DataDir:             dataDir,
+		PeerAddr:            peerAddr,
+		ClientAddr:          clientAddr,
+		InitialCluster:      fmt.Sprintf("default=%s", peerAddr),
+		InitialClusterState: embed.ClusterStateFlagNew,
+	}
+
+	if _, err := EmbedCluster(cfg, shutdownGuard, zap.NewNop()); err != nil {
+		panic(err)
+	}
+}
+
+// EmbedCluster starts an embedded etcd member as part of a (possibly
+// multi-node) cluster, per cfg. Unlike EmbedEtcd it is meant for production
+// use behind --embed: operators point every event-gateway node at the same
+// initial-cluster list and get a self-contained, clustered control plane
+// with no separate etcd deployment. It blocks until the local member
+// reports ReadyNotify(), then logs leader changes for the lifetime of the
+// process.
+func EmbedCluster(cfg config.EmbeddedConfig, shutdownGuard *sync.ShutdownGuard, log *zap.Logger) (*embed.Etcd, error) {
+	ecfg := embed.NewConfig()
+	ecfg.Name = cfg.Name
+	ecfg.Dir = cfg.DataDir
+	ecfg.InitialClusterToken = cfg.InitialClusterToken
+
+	lpurl, err := url.Parse(cfg.PeerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing peer-addr: %w", err)
+	}
+	lcurl, err := url.Parse(cfg.ClientAddr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing client-addr: %w", err)
+	}
+	ecfg.LPUrls = []url.URL{*lpurl}
+	ecfg.LCUrls = []url.URL{*lcurl}
+	ecfg.APUrls = ecfg.LPUrls
+	ecfg.ACUrls = ecfg.LCUrls
+
+	if cfg.InitialCluster != "" {
+		if _, err := parseInitialCluster(cfg.InitialCluster); err != nil {
+			return nil, fmt.Errorf("invalid initial-cluster: %w", err)
+		}
+		ecfg.InitialCluster = cfg.InitialCluster
+	} else {
+		ecfg.InitialCluster = ecfg.InitialClusterFromName(ecfg.Name)
+	}
+
+	switch cfg.InitialClusterState {
+	case "existing":
+		ecfg.ClusterState = embed.ClusterStateFlagExisting
+	default:
+		ecfg.ClusterState = embed.ClusterStateFlagNew
+	}
+
+	if err := applyPeerTLS(ecfg, cfg.DataDir, cfg.PeerTLS); err != nil {
+		return nil, fmt.Errorf("configuring peer TLS: %w", err)
+	}
+	if err := applyClientTLS(ecfg, cfg.DataDir, cfg.ClientTLS); err != nil {
+		return nil, fmt.Errorf("configuring client TLS: %w", err)
+	}
+
+	e, err := embed.StartEtcd(ecfg)
+	if err != nil {
+		return nil, fmt.Errorf("starting embedded etcd: %w", err)
+	}
+
+	select {
+	case <-e.Server.ReadyNotify():
+		log.Info("Embedded etcd member is ready.",
+			zap.String("name", cfg.Name),
+			zap.String("initial-cluster", ecfg.InitialCluster))
+	case <-time.After(startupTimeout):
+		e.Server.Stop()
+		return nil, fmt.Errorf("embedded etcd took longer than %s to start", startupTimeout)
+	}
+
+	watchMembership(e, log)
+
+	shutdownGuard.Add(1)
+	go func() {
+		defer shutdownGuard.Done()
+		<-shutdownGuard.ShuttingDown()
+		e.Close()
+	}()
+
+	return e, nil
+}
+
+// watchMembership logs leader changes for the lifetime of the embedded
+// member, so cluster topology changes show up in the same zap output as
+// everything else the gateway logs.
+func watchMembership(e *embed.Etcd, log *zap.Logger) {
+	go func() {
+		for {
+			// LeaderChangedNotify hands out a channel that is closed (never
+			// sent on) at the next leader change, so fetch a fresh one on
+			// every iteration.
+			select {
+			case <-e.Server.LeaderChangedNotify():
+			case <-e.Server.StopNotify():
+				return
+			}
+
+			members := e.Server.Cluster().Members()
+			names := make([]string, 0, len(members))
+			for _, m := range members {
+				names = append(names, m.Name)
+			}
+
+			log.Info("Embedded etcd leader changed.",
+				zap.Uint64("leader-id", uint64(e.Server.Leader())),
+				zap.Strings("members", names))
+		}
+	}()
+}
+
+func applyPeerTLS(ecfg *embed.Config, dataDir string, tlsCfg config.EmbeddedTLSConfig) error {
+	if !tlsCfg.Enabled() {
+		return nil
+	}
+
+	certFile, keyFile, err := certFilesFor(dataDir, "peer", tlsCfg.TLSConfig)
+	if err != nil {
+		return err
+	}
+
+	ecfg.PeerTLSInfo.CertFile = certFile
+	ecfg.PeerTLSInfo.KeyFile = keyFile
+	ecfg.PeerTLSInfo.TrustedCAFile = tlsCfg.CA
+	ecfg.PeerTLSInfo.ClientCertAuth = tlsCfg.ClientCertAuth
+
+	return nil
+}
+
+func applyClientTLS(ecfg *embed.Config, dataDir string, tlsCfg config.EmbeddedTLSConfig) error {
+	if !tlsCfg.Enabled() {
+		return nil
+	}
+
+	certFile, keyFile, err := certFilesFor(dataDir, "client", tlsCfg.TLSConfig)
+	if err != nil {
+		return err
+	}
+
+	ecfg.ClientTLSInfo.CertFile = certFile
+	ecfg.ClientTLSInfo.KeyFile = keyFile
+	ecfg.ClientTLSInfo.TrustedCAFile = tlsCfg.CA
+	ecfg.ClientTLSInfo.ClientCertAuth = tlsCfg.ClientCertAuth
+
+	return nil
+}
+
+// certFilesFor returns the cert/key file paths etcd's embed.TLSInfo should
+// load for the peer or client listener (name is "peer" or "client", used
+// only to keep the two auto-generated files apart on disk). etcd's TLSInfo
+// is file-based, so when tlsCfg.AutoCerts is set this generates the same
+// kind of short-lived self-signed certificate the HTTP listeners use for
+// "auto-certs" and writes it under dataDir instead of handing etcd an
+// in-memory certificate it can't accept.
+func certFilesFor(dataDir, name string, tlsCfg config.TLSConfig) (certFile, keyFile string, err error) {
+	if !tlsCfg.AutoCerts {
+		return tlsCfg.Cert, tlsCfg.Key, nil
+	}
+
+	cert, err := httpapi.GenerateSelfSignedCert()
+	if err != nil {
+		return "", "", fmt.Errorf("generating self-signed %s cert: %w", name, err)
+	}
+
+	certFile = filepath.Join(dataDir, name+"-auto-cert.pem")
+	keyFile = filepath.Join(dataDir, name+"-auto-key.pem")
+	if err := writeCertPEM(certFile, cert); err != nil {
+		return "", "", err
+	}
+	if err := writeKeyPEM(keyFile, cert); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+func writeCertPEM(path string, cert *tls.Certificate) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+}
+
+func writeKeyPEM(path string, cert *tls.Certificate) error {
+	key, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("unsupported auto-cert private key type %T", cert.PrivateKey)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return pem.Encode(f, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+// parseInitialCluster splits a comma-separated "name=peerURL" list. It's
+// used to validate the operator-supplied initial-cluster value from the
+// "embedded:" config section before handing the raw string to embed.Config, which otherwise fails
+// with a much less specific error.
+func parseInitialCluster(s string) (map[string]string, error) {
+	members := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid initial-cluster entry %q, want name=peerURL", pair)
+		}
+		members[parts[0]] = parts[1]
+	}
+	return members, nil
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/serverless/event-gateway/backend"
+	eventgatewaysync "github.com/serverless/event-gateway/internal/sync"
+)
+
+// Config is shared configuration for the events and config HTTP APIs.
+type Config struct {
+	KV            backend.Backend
+	Log           *zap.Logger
+	Port          uint
+	ShutdownGuard *eventgatewaysync.ShutdownGuard
+
+	// Certs serves the listener's certificate and is swapped atomically
+	// on a config reload. Nil means TLS is disabled. The old pre-config-file
+	// CLI flags (--events-tls-cert and friends) are folded into the
+	// config.TLSConfig that builds this CertStore rather than being read
+	// directly by the API-serving code, so there's only ever one TLS path.
+	Certs *CertStore
+
+	// Healthy, when set, is consulted by HealthGate; it returns 503 instead
+	// of routing while the KV backend is unhealthy so upstream load
+	// balancers can drain this instance. Nil means always healthy, e.g. for
+	// the config API which doesn't sit on the event path.
+	Healthy func() bool
+}
+
+// HealthGate wraps next so it returns 503 Service Unavailable instead of
+// running the handler while cfg.Healthy reports the backend unhealthy. The
+// events/config API servers (package api) are expected to wrap their router
+// with this when they build the listener's http.Handler from this Config;
+// StartConfigAPI should leave cfg.Healthy nil since it doesn't sit on the
+// event path.
+func (cfg Config) HealthGate(next http.Handler) http.Handler {
+	if cfg.Healthy == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.Healthy() {
+			http.Error(w, "event gateway KV backend unhealthy", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/serverless/event-gateway/internal/config"
+)
+
+// CertStore holds the current TLS certificate for a listener behind an
+// atomic pointer so it can be swapped out by a config reload without
+// restarting the server. tls.Config.GetCertificate is wired to the store's
+// GetCertificate method so
+// in-flight handshakes always see a consistent certificate.
+type CertStore struct {
+	current atomic.Value // *tls.Certificate
+}
+
+// NewCertStore builds a CertStore from a TLSConfig, loading the cert/key
+// pair from disk or generating a self-signed one when AutoCerts is set.
+func NewCertStore(cfg config.TLSConfig) (*CertStore, error) {
+	s := &CertStore{}
+	if err := s.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload loads a new certificate into the store, replacing the one served
+// to new connections. Existing connections are unaffected.
+func (s *CertStore) Reload(cfg config.TLSConfig) error {
+	if cfg.AutoCerts {
+		cert, err := GenerateSelfSignedCert()
+		if err != nil {
+			return fmt.Errorf("generating self-signed cert: %w", err)
+		}
+		s.current.Store(cert)
+		return nil
+	}
+
+	if cfg.Cert == "" || cfg.Key == "" {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return fmt.Errorf("loading cert/key pair: %w", err)
+	}
+	s.current.Store(&cert)
+	return nil
+}
+
+// GetCertificate satisfies tls.Config.GetCertificate.
+func (s *CertStore) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, _ := s.current.Load().(*tls.Certificate)
+	if cert == nil {
+		return nil, fmt.Errorf("no TLS certificate loaded")
+	}
+	return cert, nil
+}
+
+// TLSConfig builds a *tls.Config backed by this store, adding client-cert
+// verification against the configured CA when one is present.
+func (s *CertStore) TLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		GetCertificate: s.GetCertificate,
+	}
+
+	if cfg.CA != "" {
+		pool, err := loadCAPool(cfg.CA)
+		if err != nil {
+			return nil, fmt.Errorf("loading CA pool: %w", err)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.SkipCA {
+			tlsCfg.ClientAuth = tls.RequestClientCert
+		}
+	}
+
+	return tlsCfg, nil
+}
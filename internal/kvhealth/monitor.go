@@ -0,0 +1,397 @@
+// Package kvhealth watches the health of the configured backend.Backend,
+// modeled on PD's leadership watch loop: a lightweight heartbeat runs on a
+// fixed interval, and a stretch of consecutive failures triggers a rebuild
+// of the client against the next configured host.
+package kvhealth
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/serverless/event-gateway/backend"
+	"github.com/serverless/event-gateway/internal/metrics"
+)
+
+const (
+	checkInterval = 10 * time.Second
+	sentinelKey   = "/serverless-event-gateway/health"
+)
+
+// Monitor wraps a backend.Backend and transparently rebuilds it against the
+// next host in the list whenever it's found unhealthy. Callers hold onto
+// the Monitor (it implements backend.Backend itself) instead of the raw
+// client, so a reconnect is invisible to them: every Watch/WatchTree the
+// Monitor itself handed out is re-subscribed against the new backend, and
+// the same applies if the backend ends a watch on its own (e.g. because a
+// requested revision was compacted away) without a reconnect happening at
+// all.
+type Monitor struct {
+	hostURLs []string // one backend.New-able URL per candidate host
+	hostIdx  int32
+
+	unhealthyTimeout time.Duration
+	lastHealthy      int64 // unix nanos, accessed atomically
+
+	current atomic.Value // backend.Backend
+
+	watchesMu   sync.Mutex
+	watches     []*watchSub
+	treeWatches []*treeWatchSub
+
+	log *zap.Logger
+}
+
+// watchSub is one outstanding Watch() the Monitor is responsible for
+// keeping alive across reconnects. generation is bumped every time
+// subscribeWatch (re-)subscribes it; a forwarding goroutine whose inner
+// channel ends compares its own generation against the current one before
+// resubscribing, so a resubscribe already triggered by reconnect() doesn't
+// race a second, redundant one triggered by the old goroutine noticing its
+// channel closed.
+type watchSub struct {
+	key        string
+	out        chan *backend.KVPair
+	callerStop <-chan struct{}
+	closeOut   sync.Once
+
+	mu         sync.Mutex
+	generation uint64
+	innerStop  chan struct{} // closed to end the current inner subscription
+}
+
+// treeWatchSub is the WatchTree() equivalent of watchSub.
+type treeWatchSub struct {
+	prefix     string
+	out        chan []*backend.KVPair
+	callerStop <-chan struct{}
+	closeOut   sync.Once
+
+	mu         sync.Mutex
+	generation uint64
+	innerStop  chan struct{}
+}
+
+// NewMonitor wraps an already-connected backend with health monitoring.
+// hostURLs is the full list of backend.New-able URLs the monitor cycles
+// through on reconnect (e.g. one "etcd://host:2379" per --db-hosts entry).
+// unhealthyTimeout is how long the sentinel Get can keep failing before the
+// monitor rebuilds the client.
+func NewMonitor(kv backend.Backend, hostURLs []string, unhealthyTimeout time.Duration, log *zap.Logger) *Monitor {
+	m := &Monitor{
+		hostURLs:         hostURLs,
+		unhealthyTimeout: unhealthyTimeout,
+		log:              log,
+	}
+	m.current.Store(kv)
+	m.markHealthy()
+	return m
+}
+
+// Start runs the heartbeat loop until ctx is done.
+func (m *Monitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.heartbeat(ctx)
+		}
+	}
+}
+
+func (m *Monitor) heartbeat(ctx context.Context) {
+	_, err := m.store().Get(sentinelKey)
+	if err == nil || err == backend.ErrKeyNotFound {
+		// Nothing has ever written the sentinel key; a clean "not found"
+		// still proves the round trip to the backend succeeded.
+		m.markHealthy()
+		return
+	}
+
+	if !m.Healthy() {
+		m.log.Error("KV store still unhealthy past timeout, reconnecting to next host.", zap.Error(err), zap.Duration("unhealthy-for", m.unhealthyFor()))
+		m.reconnect(ctx)
+	} else {
+		m.log.Warn("KV sentinel check failed.", zap.Error(err))
+	}
+}
+
+// Healthy reports whether a healthy response has been observed within
+// unhealthyTimeout.
+func (m *Monitor) Healthy() bool {
+	healthy := m.unhealthyFor() < m.unhealthyTimeout
+	if healthy {
+		metrics.KVHealthy.Set(1)
+	} else {
+		metrics.KVHealthy.Set(0)
+	}
+	return healthy
+}
+
+func (m *Monitor) unhealthyFor() time.Duration {
+	last := atomic.LoadInt64(&m.lastHealthy)
+	return time.Since(time.Unix(0, last))
+}
+
+func (m *Monitor) markHealthy() {
+	atomic.StoreInt64(&m.lastHealthy, time.Now().UnixNano())
+	metrics.KVHealthy.Set(1)
+}
+
+func (m *Monitor) store() backend.Backend {
+	return m.current.Load().(backend.Backend)
+}
+
+// reconnect rebuilds the backend against the next host in the configured
+// list, then re-subscribes every outstanding Watch/WatchTree against it so
+// the swap stays invisible to callers.
+func (m *Monitor) reconnect(ctx context.Context) {
+	if len(m.hostURLs) == 0 {
+		m.log.Error("No alternate hosts configured to reconnect to.")
+		return
+	}
+
+	next := atomic.AddInt32(&m.hostIdx, 1)
+	hostURL := m.hostURLs[int(next)%len(m.hostURLs)]
+
+	kv, err := backend.New(hostURL)
+	if err != nil {
+		m.log.Error("Failed to reconnect KV client.", zap.String("host", hostURL), zap.Error(err))
+		return
+	}
+
+	old := m.store()
+	m.current.Store(kv)
+	old.Close()
+
+	m.resubscribeAll()
+
+	metrics.KVReconnectsTotal.Inc()
+	m.markHealthy()
+	m.log.Info("Reconnected KV client.", zap.String("host", hostURL))
+}
+
+// resubscribeAll re-establishes every watch/tree-watch the Monitor is
+// tracking against whatever backend is current.
+func (m *Monitor) resubscribeAll() {
+	m.watchesMu.Lock()
+	watches := append([]*watchSub(nil), m.watches...)
+	treeWatches := append([]*treeWatchSub(nil), m.treeWatches...)
+	m.watchesMu.Unlock()
+
+	for _, w := range watches {
+		select {
+		case <-w.callerStop:
+			continue // already torn down by its caller; nothing to resubscribe
+		default:
+			m.subscribeWatch(w)
+		}
+	}
+	for _, w := range treeWatches {
+		select {
+		case <-w.callerStop:
+			continue
+		default:
+			m.subscribeTreeWatch(w)
+		}
+	}
+}
+
+// Get, Put, Delete, List, AtomicPut and AtomicDelete implement
+// backend.Backend by delegating to whichever client is current, so a
+// reconnect is transparent to anything holding a Monitor.
+
+func (m *Monitor) Get(key string) (*backend.KVPair, error) { return m.store().Get(key) }
+
+func (m *Monitor) Put(key string, value []byte, options *backend.WriteOptions) error {
+	return m.store().Put(key, value, options)
+}
+
+func (m *Monitor) Delete(key string) error { return m.store().Delete(key) }
+
+func (m *Monitor) List(prefix string) ([]*backend.KVPair, error) { return m.store().List(prefix) }
+
+func (m *Monitor) AtomicPut(key string, value []byte, previous *backend.KVPair, options *backend.WriteOptions) (bool, *backend.KVPair, error) {
+	return m.store().AtomicPut(key, value, previous, options)
+}
+
+func (m *Monitor) AtomicDelete(key string, previous *backend.KVPair) (bool, error) {
+	return m.store().AtomicDelete(key, previous)
+}
+
+// Watch implements backend.Backend. Unlike the other methods it can't just
+// delegate to the current backend once: a reconnect would leave the
+// caller's channel attached to the now-closed old backend with nothing
+// re-subscribing it. Instead the Monitor owns a long-lived goroutine per
+// Watch that forwards from whichever backend is current, and re-subscribes
+// on every reconnect and whenever the backend ends the watch on its own
+// (e.g. a compacted revision).
+func (m *Monitor) Watch(key string, stopCh <-chan struct{}) (<-chan *backend.KVPair, error) {
+	sub := &watchSub{key: key, out: make(chan *backend.KVPair), callerStop: stopCh}
+
+	m.watchesMu.Lock()
+	m.watches = append(m.watches, sub)
+	m.watchesMu.Unlock()
+
+	m.subscribeWatch(sub)
+
+	go func() {
+		<-stopCh
+		m.removeWatch(sub)
+		sub.mu.Lock()
+		if sub.innerStop != nil {
+			close(sub.innerStop)
+			sub.innerStop = nil
+		}
+		sub.mu.Unlock()
+		sub.closeOut.Do(func() { close(sub.out) })
+	}()
+
+	return sub.out, nil
+}
+
+// WatchTree is the WatchTree equivalent of Watch; see its doc comment.
+func (m *Monitor) WatchTree(prefix string, stopCh <-chan struct{}) (<-chan []*backend.KVPair, error) {
+	sub := &treeWatchSub{prefix: prefix, out: make(chan []*backend.KVPair), callerStop: stopCh}
+
+	m.watchesMu.Lock()
+	m.treeWatches = append(m.treeWatches, sub)
+	m.watchesMu.Unlock()
+
+	m.subscribeTreeWatch(sub)
+
+	go func() {
+		<-stopCh
+		m.removeTreeWatch(sub)
+		sub.mu.Lock()
+		if sub.innerStop != nil {
+			close(sub.innerStop)
+			sub.innerStop = nil
+		}
+		sub.mu.Unlock()
+		sub.closeOut.Do(func() { close(sub.out) })
+	}()
+
+	return sub.out, nil
+}
+
+// subscribeWatch (re-)subscribes sub against the current backend and
+// forwards values until the inner subscription ends, at which point it
+// resubscribes itself unless the caller is done or a concurrent reconnect
+// already started a newer generation of the subscription. It never closes
+// sub.out itself; the goroutine Watch() starts to wait on callerStop owns
+// that close so it only ever happens once.
+func (m *Monitor) subscribeWatch(sub *watchSub) {
+	sub.mu.Lock()
+	if sub.innerStop != nil {
+		close(sub.innerStop)
+	}
+	sub.generation++
+	gen := sub.generation
+	inner := make(chan struct{})
+	sub.innerStop = inner
+	sub.mu.Unlock()
+
+	ch, err := m.store().Watch(sub.key, inner)
+	if err != nil {
+		m.log.Warn("Failed to (re-)subscribe KV watch; will retry on the next reconnect.", zap.String("key", sub.key), zap.Error(err))
+		return
+	}
+
+	go func() {
+		for pair := range ch {
+			select {
+			case sub.out <- pair:
+			case <-sub.callerStop:
+				return
+			}
+		}
+
+		select {
+		case <-sub.callerStop:
+			return
+		default:
+		}
+
+		sub.mu.Lock()
+		current := sub.generation
+		sub.mu.Unlock()
+		if current == gen {
+			m.subscribeWatch(sub)
+		}
+	}()
+}
+
+func (m *Monitor) subscribeTreeWatch(sub *treeWatchSub) {
+	sub.mu.Lock()
+	if sub.innerStop != nil {
+		close(sub.innerStop)
+	}
+	sub.generation++
+	gen := sub.generation
+	inner := make(chan struct{})
+	sub.innerStop = inner
+	sub.mu.Unlock()
+
+	ch, err := m.store().WatchTree(sub.prefix, inner)
+	if err != nil {
+		m.log.Warn("Failed to (re-)subscribe KV tree watch; will retry on the next reconnect.", zap.String("prefix", sub.prefix), zap.Error(err))
+		return
+	}
+
+	go func() {
+		for pairs := range ch {
+			select {
+			case sub.out <- pairs:
+			case <-sub.callerStop:
+				return
+			}
+		}
+
+		select {
+		case <-sub.callerStop:
+			return
+		default:
+		}
+
+		sub.mu.Lock()
+		current := sub.generation
+		sub.mu.Unlock()
+		if current == gen {
+			m.subscribeTreeWatch(sub)
+		}
+	}()
+}
+
+func (m *Monitor) removeWatch(sub *watchSub) {
+	m.watchesMu.Lock()
+	defer m.watchesMu.Unlock()
+	for i, w := range m.watches {
+		if w == sub {
+			m.watches = append(m.watches[:i], m.watches[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *Monitor) removeTreeWatch(sub *treeWatchSub) {
+	m.watchesMu.Lock()
+	defer m.watchesMu.Unlock()
+	for i, w := range m.treeWatches {
+		if w == sub {
+			m.treeWatches = append(m.treeWatches[:i], m.treeWatches[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *Monitor) Close() error {
+	return m.store().Close()
+}
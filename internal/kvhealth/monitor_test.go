@@ -0,0 +1,48 @@
+package kvhealth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/serverless/event-gateway/backend"
+)
+
+func TestMonitorReconnectResubscribesLiveWatch(t *testing.T) {
+	first, err := backend.New("memory://")
+	if err != nil {
+		t.Fatalf("backend.New: %v", err)
+	}
+
+	m := NewMonitor(first, []string{"memory://", "memory://"}, time.Minute, zap.NewNop())
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	ch, err := m.Watch("key", stopCh)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	before := m.store()
+	m.reconnect(context.Background())
+	after := m.store()
+	if before == after {
+		t.Fatal("reconnect did not swap the current backend")
+	}
+
+	if err := m.Put("key", []byte("v1"), nil); err != nil {
+		t.Fatalf("Put against the reconnected backend: %v", err)
+	}
+
+	select {
+	case pair := <-ch:
+		if pair == nil || string(pair.Value) != "v1" {
+			t.Fatalf("expected the watch to observe v1 after reconnect, got %+v", pair)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch obtained before reconnect was never re-subscribed against the new backend")
+	}
+}
@@ -0,0 +1,59 @@
+// Package metrics holds the Prometheus collectors shared across the event
+// gateway's components. Collectors are registered once in main's init() and
+// updated from wherever the corresponding event happens.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RequestDuration tracks how long events and config API requests take.
+var RequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "eventgateway",
+		Name:      "request_duration_seconds",
+		Help:      "Request duration distribution in seconds.",
+	},
+	[]string{"method", "path", "status"},
+)
+
+// DroppedPubSubEvents counts events dropped because no subscriber could keep
+// up with the router's pub/sub backlog.
+var DroppedPubSubEvents = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "eventgateway",
+		Name:      "dropped_pubsub_events_total",
+		Help:      "Total number of pub/sub events dropped because subscribers couldn't keep up.",
+	},
+)
+
+// KVHealthy reports whether the KV health watchdog currently considers the
+// backing store reachable (1) or not (0). See internal/kvhealth.
+var KVHealthy = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "eventgateway",
+		Name:      "kv_healthy",
+		Help:      "Whether the KV store connection is currently considered healthy (1) or not (0).",
+	},
+)
+
+// KVReconnectsTotal counts how many times the KV health watchdog has had to
+// rebuild the backend client against the next host in its list.
+var KVReconnectsTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "eventgateway",
+		Name:      "kv_reconnects_total",
+		Help:      "Total number of times the KV client was rebuilt after the store was found unhealthy.",
+	},
+)
+
+// MustRegisterAll registers every collector in this package with the
+// default Prometheus registry. Every binary that imports cmd/event-gateway/app
+// (and therefore ends up running code that updates these collectors) must
+// call this once from its own init() - cmd/event-gateway and
+// cmd/eventgateway-dump-metrics both do, since Prometheus registration isn't
+// transitive through an import alone.
+func MustRegisterAll() {
+	prometheus.MustRegister(RequestDuration)
+	prometheus.MustRegister(DroppedPubSubEvents)
+	prometheus.MustRegister(KVHealthy)
+	prometheus.MustRegister(KVReconnectsTotal)
+}